@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// ChallengeType identifies the kind of anti-bot interstitial a page showed
+// instead of the product content we were expecting.
+type ChallengeType string
+
+const (
+	ChallengeNone       ChallengeType = ""
+	ChallengeCloudflare ChallengeType = "cloudflare"
+	ChallengePerimeterX ChallengeType = "perimeterx"
+	ChallengeDataDome   ChallengeType = "datadome"
+	ChallengeAkamai     ChallengeType = "akamai"
+	ChallengeHTTPBlock  ChallengeType = "http_block"
+)
+
+// IsTransient reports whether challenge is the kind that commonly clears on
+// its own after a few seconds, like Cloudflare's "Just a moment..." JS
+// challenge, as opposed to a hard block that won't pass no matter how long
+// we wait.
+func (c ChallengeType) IsTransient() bool {
+	return c == ChallengeCloudflare
+}
+
+// challengeWaitDuration is how long we give a transient challenge to clear
+// before retrying the extraction once.
+const challengeWaitDuration = 7 * time.Second
+
+const challengeDetectScript = `
+(() => {
+	const title = document.title || '';
+	const body = document.body ? document.body.innerText.slice(0, 2000) : '';
+	const text = (title + ' ' + body).toLowerCase();
+
+	if (text.includes('checking your browser') || text.includes('just a moment') || text.includes('cloudflare')) {
+		return 'cloudflare';
+	}
+	if (text.includes('perimeterx') || text.includes('please verify you are a human')) {
+		return 'perimeterx';
+	}
+	if (text.includes('datadome')) {
+		return 'datadome';
+	}
+	if (text.includes('akamai') || text.includes('reference id')) {
+		return 'akamai';
+	}
+	if (text.includes('403 forbidden') || text.includes('access denied') || text.includes('too many requests')) {
+		return 'http_block';
+	}
+	return '';
+})()
+`
+
+// detectChallenge inspects the current tab's DOM/title for known anti-bot
+// signals. It returns ChallengeNone if the page looks ordinary or the
+// inspection itself fails (e.g. the tab is already gone).
+func detectChallenge(ctx context.Context) ChallengeType {
+	var detected string
+	if err := chromedp.Run(ctx, chromedp.EvaluateAsDevTools(challengeDetectScript, &detected)); err != nil {
+		return ChallengeNone
+	}
+	return ChallengeType(detected)
+}
@@ -0,0 +1,78 @@
+package main
+
+import "math/rand"
+
+// Fingerprint is the set of browser characteristics randomized per tab to
+// make large-scale scraping harder to pattern-match and block.
+type Fingerprint struct {
+	UserAgent      string
+	AcceptLanguage string
+	Timezone       string
+	ViewportWidth  int64
+	ViewportHeight int64
+}
+
+type weightedOption[T any] struct {
+	value  T
+	weight int
+}
+
+func pickWeighted[T any](options []weightedOption[T]) T {
+	total := 0
+	for _, o := range options {
+		total += o.weight
+	}
+
+	n := rand.Intn(total)
+	for _, o := range options {
+		if n < o.weight {
+			return o.value
+		}
+		n -= o.weight
+	}
+
+	return options[len(options)-1].value
+}
+
+var userAgents = []weightedOption[string]{
+	{value: `Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/121.0.0.0 Safari/537.36`, weight: 5},
+	{value: `Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/121.0.0.0 Safari/537.36`, weight: 3},
+	{value: `Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/121.0.0.0 Safari/537.36`, weight: 1},
+}
+
+var acceptLanguages = []weightedOption[string]{
+	{value: "en-US,en;q=0.9", weight: 6},
+	{value: "en-GB,en;q=0.9", weight: 2},
+	{value: "es-ES,es;q=0.9,en;q=0.8", weight: 1},
+}
+
+var timezones = []weightedOption[string]{
+	{value: "America/New_York", weight: 4},
+	{value: "America/Los_Angeles", weight: 3},
+	{value: "America/Chicago", weight: 2},
+	{value: "Europe/London", weight: 1},
+}
+
+type viewportSize struct {
+	width, height int64
+}
+
+var viewports = []weightedOption[viewportSize]{
+	{value: viewportSize{1920, 1080}, weight: 5},
+	{value: viewportSize{1366, 768}, weight: 3},
+	{value: viewportSize{1536, 864}, weight: 2},
+}
+
+// randomFingerprint draws an independent weighted pick for each
+// characteristic, so the combination of UA/language/timezone/viewport isn't
+// always the same handful of tuples.
+func randomFingerprint() Fingerprint {
+	viewport := pickWeighted(viewports)
+	return Fingerprint{
+		UserAgent:      pickWeighted(userAgents),
+		AcceptLanguage: pickWeighted(acceptLanguages),
+		Timezone:       pickWeighted(timezones),
+		ViewportWidth:  viewport.width,
+		ViewportHeight: viewport.height,
+	}
+}
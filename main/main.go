@@ -1,19 +1,19 @@
 package main
 
 import (
-	"context"
 	"database/sql"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/chromedp/cdproto/network"
-	"github.com/chromedp/chromedp"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
@@ -28,7 +28,14 @@ type Product struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// maxTabUses bounds how many scrapes a single tab handles before the pool
+// recycles it for a fresh one.
+const maxTabUses = 25
+
 func main() {
+	concurrency := flag.Int("concurrency", 4, "number of concurrent scraper tabs")
+	flag.Parse()
+
 	if err := godotenv.Load(); err != nil {
 		log.Fatal("Error loading .env file")
 	}
@@ -63,32 +70,42 @@ func main() {
 	}
 	defer db.Close()
 
-	// Create browser context with additional anti-detection flags
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.UserAgent(`Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/121.0.0.0 Safari/537.36`),
-		chromedp.DisableGPU,
-		chromedp.NoDefaultBrowserCheck,
-		chromedp.NoFirstRun,
-		// The following flag can help obscure headless behavior
-		chromedp.Flag("disable-blink-features", "AutomationControlled"),
-		// For testing, you might temporarily remove headless mode:
-		chromedp.Headless,
-		chromedp.NoSandbox,
-		chromedp.Flag("blink-settings", "scriptEnabled=false, imagesEnabled=false"),
-	)
-
-	allocCtx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
-	defer cancel()
-
-	// Create context without debug logging
-	ctx, cancel := chromedp.NewContext(allocCtx)
-	defer cancel()
+	proxiesFile := os.Getenv("PROXIES_FILE")
+	if proxiesFile == "" {
+		proxiesFile = "proxies.json"
+	}
+	proxyPool, err := loadProxyPool(proxiesFile)
+	if err != nil {
+		log.Fatalf("Failed to load %s: %v", proxiesFile, err)
+	}
+
+	// The registry is rebuilt from urls.json at the start of every scrape
+	// cycle; seed it with the default rule so an empty registry isn't used
+	// if a scrape somehow starts before the first cycle's reload.
+	initialRegistry := NewScraperRegistry(Manifest{}, defaultResourceFilter())
+	pool, err := NewScrapePool(*concurrency, maxTabUses, initialRegistry, proxyPool)
+	if err != nil {
+		log.Fatalf("Failed to start scrape pool: %v", err)
+	}
+
+	// Close the browser cleanly on SIGTERM/SIGINT instead of leaving a
+	// headless Chrome process behind.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		sig := <-sigCh
+		log.Printf("Received %v, shutting down scrape pool", sig)
+		pool.Shutdown()
+		os.Exit(0)
+	}()
+
+	notifier := notifierFromEnv()
 
 	go startAPI(db)
 
 	// Run scraper in a loop
 	for {
-		if err := scrapeProducts(ctx, db); err != nil {
+		if err := scrapeProducts(pool, db, notifier); err != nil {
 			log.Printf("Error in scrape cycle: %v", err)
 		}
 		// time.Sleep(time.Minute * 5)
@@ -96,121 +113,47 @@ func main() {
 
 }
 
-func scrapeProducts(ctx context.Context, db *sql.DB) error {
-	// Read the file contents
-	data, err := os.ReadFile("urls.json")
+func scrapeProducts(pool *ScrapePool, db *sql.DB, notifier Notifier) error {
+	manifest, err := loadManifest("urls.json")
 	if err != nil {
-		log.Printf("Error reading urls.json: %v", err)
+		log.Printf("Error loading urls.json: %v", err)
 		return err
 	}
 
-	// Unmarshal the JSON into a []string slice
-	var urls []string
-	if err := json.Unmarshal(data, &urls); err != nil {
-		log.Printf("Error parsing urls.json: %v", err)
-		return err
-	}
+	// Rebuild the registry each cycle so edits to site_rules take effect
+	// without restarting the scraper.
+	pool.SetRegistry(NewScraperRegistry(manifest, defaultResourceFilter()))
+
+	log.Printf("Scraping %d products", len(manifest.URLs))
 
-	log.Printf("Scraping %d products", len(urls))
-
-	// Set up flags for each new Chrome process to further obfuscate scraping activity.
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.UserAgent(`Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/121.0.0.0 Safari/537.36`),
-		chromedp.DisableGPU,
-		chromedp.NoDefaultBrowserCheck,
-		chromedp.NoFirstRun,
-		chromedp.Flag("disable-blink-features", "AutomationControlled"),
-		// For debugging, you can disable headless mode:
-		// chromedp.Headful,
-		chromedp.Headless,
-		chromedp.NoSandbox,
-		chromedp.Flag("blink-settings", "scriptEnabled=false, imagesEnabled=false"),
-		chromedp.Flag("ignore-certificate-errors", "true"),
-		chromedp.Flag("disable-http2", "true"), // Experimental flag – may or may not help.
-		chromedp.Flag("disable-extensions", "true"),
-	)
-
-	for _, url := range urls {
-		// Optional randomized delay before launching a new browser process.
+	for _, url := range manifest.URLs {
+		state, stateErr := fetchScrapeState(db, url)
+		hadState := stateErr == nil
+		if hadState && time.Now().Before(state.BlockedUntil) {
+			log.Printf("Skipping %s: backing off until %s (last challenge: %s)", url, state.BlockedUntil, state.LastChallenge)
+			continue
+		}
+
+		// Optional randomized delay to spread out navigations.
 		time.Sleep(time.Duration(2+rand.Intn(5)) * time.Second)
 
-		// Create a fresh ExecAllocator and derived context for each URL.
-		allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), opts...)
-		tbCtx, cancel := chromedp.NewContext(allocCtx)
-		// Increase the timeout to 60 seconds to allow slower pages to load.
-		tbCtx, timeoutCancel := context.WithTimeout(tbCtx, 60*time.Second)
-
-		var name, imageURL string
-		var inStock bool
-
-		log.Printf("Navigating to: %s", url)
-
-		err := chromedp.Run(tbCtx,
-			network.Enable(),
-			chromedp.Navigate(url),
-			// Wait for the <body> to be ready.
-			chromedp.WaitReady("body"),
-			// Wait for a specific element that signifies the product is loaded.
-			// If this selector never appears (maybe due to a CAPTCHA or error), it will timeout.
-			chromedp.WaitVisible(`h1[automation-id="productName"], .product-title, h1`, chromedp.ByQuery),
-			// Evaluate the product name.
-			chromedp.EvaluateAsDevTools(`
-				(() => {
-					const nameElement = document.querySelector('h1[automation-id="productName"]') ||
-										  document.querySelector('.product-title') ||
-										  document.querySelector('h1');
-					return nameElement ? nameElement.textContent.trim() : '';
-				})()
-			`, &name),
-			// Evaluate if the product is in stock.
-			chromedp.EvaluateAsDevTools(`
-				(() => {
-					const outOfStock = document.querySelector('[automation-id="outOfStockMessage"]') ||
-										 document.querySelector('.out-of-stock-msg') ||
-										 document.querySelector('.oos-overlay');
-					return !outOfStock;
-				})()
-			`, &inStock),
-		)
-		timeoutCancel()
-		cancel()
-		allocCancel()
-
-		// If we encountered an error, try to capture a screenshot for debugging.
-		if err != nil {
-			allocCtx, allocCancel = chromedp.NewExecAllocator(context.Background(), opts...)
-			tbCtx, cancel = chromedp.NewContext(allocCtx)
-			tbCtx, capTimeout := context.WithTimeout(tbCtx, 10*time.Second)
-			var buf []byte
-			if errScr := chromedp.Run(tbCtx, chromedp.FullScreenshot(&buf, 90)); errScr == nil {
-				filename := fmt.Sprintf("screenshots/screenshot_%d.png", time.Now().UnixNano())
-				if errWrite := os.WriteFile(filename, buf, 0644); errWrite == nil {
-					log.Printf("Saved screenshot for %s as %s", url, filename)
-				} else {
-					log.Printf("Failed to write screenshot: %v", errWrite)
-				}
+		result := <-pool.Submit(url)
+		if result.Err != nil {
+			log.Printf("Failed to load or scrape page %s: %v", url, result.Err)
+			if err := recordScrapeFailure(db, url, state, hadState, result.Challenge); err != nil {
+				log.Printf("Failed to record scrape_state for %s: %v", url, err)
 			}
-			capTimeout()
-			cancel()
-			allocCancel()
-
-			log.Printf("Failed to load or scrape page %s: %v", url, err)
 			continue
 		}
 
-		log.Printf("Product: %s", name)
-		log.Printf("In Stock: %v", inStock)
-
-		// Update the database with scraped data.
-		product := Product{
-			URL:       url,
-			Name:      name,
-			InStock:   inStock,
-			ImageURL:  imageURL,
-			UpdatedAt: time.Now(),
+		if hadState && state.ConsecutiveFailures > 0 {
+			if err := clearScrapeFailure(db, url); err != nil {
+				log.Printf("Failed to clear scrape_state for %s: %v", url, err)
+			}
 		}
 
-		if err := updateProduct(db, product); err != nil {
+		threshold := manifest.PriceThresholds[url]
+		if err := updateProduct(db, result.Product, threshold, notifier); err != nil {
 			log.Printf("Database error: %v", err)
 		} else {
 			log.Printf("Successfully updated database")
@@ -223,16 +166,60 @@ func scrapeProducts(ctx context.Context, db *sql.DB) error {
 	return nil
 }
 
-func updateProduct(db *sql.DB, product Product) error {
+// updateProduct upserts product's row, then diffs it against whatever was
+// there before to record a product_events entry and fire a notification when
+// the product came back in stock or its price dropped below threshold (a
+// threshold of 0 disables the price-drop check for that URL).
+func updateProduct(db *sql.DB, product Product, threshold float64, notifier Notifier) error {
+	previous, prevErr := fetchProduct(db, product.URL)
+	hadPrevious := prevErr == nil
+
 	query := `
         INSERT INTO products (url, name, in_stock, price, image_url, updated_at)
         VALUES ($1, $2, $3, $4, $5, $6)
         ON CONFLICT (url) DO UPDATE
         SET name = $2, in_stock = $3, price = $4, image_url = $5, updated_at = $6
+        RETURNING id
     `
 
-	_, err := db.Exec(query, product.URL, product.Name, product.InStock, product.Price, product.ImageURL, product.UpdatedAt)
-	return err
+	var productID int
+	if err := db.QueryRow(query, product.URL, product.Name, product.InStock, product.Price, product.ImageURL, product.UpdatedAt).Scan(&productID); err != nil {
+		return err
+	}
+
+	changed := !hadPrevious || previous.InStock != product.InStock || previous.Price != product.Price
+	if !changed {
+		return nil
+	}
+
+	restocked := hadPrevious && !previous.InStock && product.InStock
+	priceDropped := threshold > 0 && product.Price > 0 && product.Price < threshold &&
+		(!hadPrevious || previous.Price >= threshold)
+
+	// restocked is checked first: a restock at a below-threshold price is
+	// reported as a restock, not a price drop.
+	reason := EventReasonNone
+	switch {
+	case restocked:
+		reason = EventReasonRestock
+	case priceDropped:
+		reason = EventReasonPriceDrop
+	}
+
+	if err := insertProductEvent(db, productID, product, reason); err != nil {
+		log.Printf("Failed to record product event for %s: %v", product.URL, err)
+	}
+
+	if notifier == nil || reason == EventReasonNone {
+		return nil
+	}
+
+	event := ProductEvent{ProductID: productID, URL: product.URL, InStock: product.InStock, Price: product.Price, Reason: reason, CreatedAt: product.UpdatedAt}
+	if err := notifier.Notify(event); err != nil {
+		log.Printf("Notification failed for %s: %v", product.URL, err)
+	}
+
+	return nil
 }
 
 func startAPI(db *sql.DB) {
@@ -280,6 +267,28 @@ func startAPI(db *sql.DB) {
 		json.NewEncoder(w).Encode(products)
 	}))
 
+	http.HandleFunc("/api/products/", corsMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id, ok := parseHistoryPath(r.URL.Path)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		events, err := fetchProductEvents(db, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(events)
+	}))
+
 	log.Printf("Starting API server on :8080")
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// Notifier is a sink that gets told about a product event worth surfacing to
+// a human: a restock, or a price drop below a configured threshold.
+type Notifier interface {
+	Notify(event ProductEvent) error
+}
+
+// multiNotifier fans a single event out to every configured sink, logging
+// (rather than failing the scrape) when a sink errors.
+type multiNotifier struct {
+	sinks []Notifier
+}
+
+func (m *multiNotifier) Notify(event ProductEvent) error {
+	for _, sink := range m.sinks {
+		if err := sink.Notify(event); err != nil {
+			log.Printf("Notification sink failed for %s: %v", event.URL, err)
+		}
+	}
+	return nil
+}
+
+// notifierFromEnv builds a Notifier from whichever sinks have their
+// configuration present in the environment. It returns nil if none are
+// configured, so callers can skip notification entirely.
+func notifierFromEnv() Notifier {
+	var sinks []Notifier
+
+	if webhookURL := os.Getenv("DISCORD_WEBHOOK_URL"); webhookURL != "" {
+		sinks = append(sinks, &discordNotifier{webhookURL: webhookURL})
+	}
+
+	if endpoint := os.Getenv("NOTIFY_WEBHOOK_URL"); endpoint != "" {
+		sinks = append(sinks, &httpNotifier{endpoint: endpoint})
+	}
+
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		sinks = append(sinks, &smtpNotifier{
+			host: host,
+			port: os.Getenv("SMTP_PORT"),
+			user: os.Getenv("SMTP_USER"),
+			pass: os.Getenv("SMTP_PASSWORD"),
+			from: os.Getenv("SMTP_FROM"),
+			to:   os.Getenv("SMTP_TO"),
+		})
+	}
+
+	if len(sinks) == 0 {
+		return nil
+	}
+	return &multiNotifier{sinks: sinks}
+}
+
+func notificationMessage(event ProductEvent) string {
+	switch event.Reason {
+	case EventReasonRestock:
+		return fmt.Sprintf("Back in stock: %s ($%.2f)", event.URL, event.Price)
+	case EventReasonPriceDrop:
+		return fmt.Sprintf("Price drop: %s is now $%.2f", event.URL, event.Price)
+	default:
+		return fmt.Sprintf("Update for %s: in stock=%v, price=$%.2f", event.URL, event.InStock, event.Price)
+	}
+}
+
+// discordNotifier posts a plain-content message to a Discord incoming
+// webhook.
+type discordNotifier struct {
+	webhookURL string
+}
+
+func (n *discordNotifier) Notify(event ProductEvent) error {
+	payload, err := json.Marshal(map[string]string{"content": notificationMessage(event)})
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post(n.webhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("discord webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// httpNotifier POSTs the raw ProductEvent as JSON to a generic endpoint.
+type httpNotifier struct {
+	endpoint string
+}
+
+func (n *httpNotifier) Notify(event ProductEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post(n.endpoint, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned status %d", n.endpoint, resp.StatusCode)
+	}
+	return nil
+}
+
+// smtpNotifier emails the configured recipient using plain SMTP auth.
+type smtpNotifier struct {
+	host, port string
+	user, pass string
+	from, to   string
+}
+
+func (n *smtpNotifier) Notify(event ProductEvent) error {
+	addr := n.host + ":" + n.port
+	auth := smtp.PlainAuth("", n.user, n.pass, n.host)
+
+	subject := "ausome-scraper alert"
+	body := notificationMessage(event)
+	msg := []byte("To: " + n.to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"\r\n" + body + "\r\n")
+
+	return smtp.SendMail(addr, auth, n.from, strings.Split(n.to, ","), msg)
+}
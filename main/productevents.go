@@ -0,0 +1,77 @@
+package main
+
+import (
+	"database/sql"
+	"time"
+)
+
+// EventReason identifies which condition caused updateProduct to record a
+// ProductEvent and notify, so a sink doesn't have to infer it from the
+// product's raw InStock/Price fields.
+type EventReason string
+
+const (
+	// EventReasonNone marks an event recorded without triggering a
+	// notification (e.g. the very first time a URL was scraped).
+	EventReasonNone      EventReason = ""
+	EventReasonRestock   EventReason = "restock"
+	EventReasonPriceDrop EventReason = "price_drop"
+)
+
+// ProductEvent is a single recorded change for a product: a stock flip, a
+// price move, or the first time a URL was scraped. updateProduct appends one
+// of these every time it sees a product's state differ from the last row in
+// the products table.
+type ProductEvent struct {
+	ID        int         `json:"id"`
+	ProductID int         `json:"product_id"`
+	URL       string      `json:"url"`
+	InStock   bool        `json:"in_stock"`
+	Price     float64     `json:"price"`
+	Reason    EventReason `json:"reason,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// fetchProduct returns the current row for url, or sql.ErrNoRows if the
+// product hasn't been seen before.
+func fetchProduct(db *sql.DB, url string) (Product, error) {
+	var p Product
+	row := db.QueryRow("SELECT id, url, name, in_stock, price, image_url, updated_at FROM products WHERE url = $1", url)
+	err := row.Scan(&p.ID, &p.URL, &p.Name, &p.InStock, &p.Price, &p.ImageURL, &p.UpdatedAt)
+	return p, err
+}
+
+// insertProductEvent records a change in product_events for later retrieval
+// via /api/products/{id}/history. reason is EventReasonNone for a change
+// that didn't clear the restock/price-drop notification bar.
+func insertProductEvent(db *sql.DB, productID int, product Product, reason EventReason) error {
+	_, err := db.Exec(`
+        INSERT INTO product_events (product_id, url, in_stock, price, reason, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `, productID, product.URL, product.InStock, product.Price, string(reason), product.UpdatedAt)
+	return err
+}
+
+// fetchProductEvents returns the event history for productID, most recent first.
+func fetchProductEvents(db *sql.DB, productID int) ([]ProductEvent, error) {
+	rows, err := db.Query(`
+        SELECT id, product_id, url, in_stock, price, reason, created_at
+        FROM product_events
+        WHERE product_id = $1
+        ORDER BY created_at DESC
+    `, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []ProductEvent
+	for rows.Next() {
+		var e ProductEvent
+		if err := rows.Scan(&e.ID, &e.ProductID, &e.URL, &e.InStock, &e.Price, &e.Reason, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Proxy is a single HTTP/SOCKS5 proxy entry, e.g. {"address":
+// "socks5://host:1080", "username": "user", "password": "pass"}. Address is
+// passed straight to chromedp.ProxyServer, so it must include the scheme.
+type Proxy struct {
+	Address  string `json:"address"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// ProxyAuth is the subset of Proxy needed to answer a CDP proxy auth
+// challenge; it's threaded through a tab's context rather than its own
+// parameter so enableResourceFiltering can pick it up without every caller
+// needing to know about proxies.
+type ProxyAuth struct {
+	Username string
+	Password string
+}
+
+const (
+	proxyFailureThreshold = 3
+	proxyCooldown         = 5 * time.Minute
+)
+
+type proxyHealth struct {
+	proxy               Proxy
+	consecutiveFailures int
+	cooldownUntil       time.Time
+}
+
+// ProxyPool hands out proxies round-robin, skipping any that have failed
+// proxyFailureThreshold times in a row until their cooldown expires.
+type ProxyPool struct {
+	mu      sync.Mutex
+	health  []*proxyHealth
+	nextIdx int
+}
+
+// NewProxyPool builds a pool from a fixed proxy list.
+func NewProxyPool(proxies []Proxy) *ProxyPool {
+	health := make([]*proxyHealth, len(proxies))
+	for i, p := range proxies {
+		health[i] = &proxyHealth{proxy: p}
+	}
+	return &ProxyPool{health: health}
+}
+
+// loadProxyPool reads a JSON array of Proxy entries from path. A missing
+// file is not an error: it just means proxy rotation is disabled.
+func loadProxyPool(path string) (*ProxyPool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var proxies []Proxy
+	if err := json.Unmarshal(data, &proxies); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(proxies) == 0 {
+		return nil, nil
+	}
+
+	return NewProxyPool(proxies), nil
+}
+
+// Acquire returns the next healthy proxy in round-robin order. ok is false
+// if every proxy is currently in cooldown (or the pool is empty).
+func (p *ProxyPool) Acquire() (Proxy, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.health) == 0 {
+		return Proxy{}, false
+	}
+
+	now := time.Now()
+	for i := 0; i < len(p.health); i++ {
+		idx := (p.nextIdx + i) % len(p.health)
+		h := p.health[idx]
+		if h.cooldownUntil.IsZero() || now.After(h.cooldownUntil) {
+			p.nextIdx = (idx + 1) % len(p.health)
+			return h.proxy, true
+		}
+	}
+
+	return Proxy{}, false
+}
+
+// ReportFailure records a failed scrape through proxy, putting it into
+// cooldown once it has failed proxyFailureThreshold times in a row.
+func (p *ProxyPool) ReportFailure(proxy Proxy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, h := range p.health {
+		if h.proxy.Address != proxy.Address {
+			continue
+		}
+		h.consecutiveFailures++
+		if h.consecutiveFailures >= proxyFailureThreshold {
+			h.cooldownUntil = time.Now().Add(proxyCooldown)
+		}
+		return
+	}
+}
+
+// ReportSuccess clears proxy's failure count.
+func (p *ProxyPool) ReportSuccess(proxy Proxy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, h := range p.health {
+		if h.proxy.Address == proxy.Address {
+			h.consecutiveFailures = 0
+			h.cooldownUntil = time.Time{}
+			return
+		}
+	}
+}
+
+type proxyAuthCtxKey struct{}
+
+// withProxyAuth attaches auth to ctx so enableResourceFiltering can answer
+// the tab's proxy CDP auth challenge.
+func withProxyAuth(ctx context.Context, auth *ProxyAuth) context.Context {
+	if auth == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, proxyAuthCtxKey{}, auth)
+}
+
+func proxyAuthFromContext(ctx context.Context) *ProxyAuth {
+	auth, _ := ctx.Value(proxyAuthCtxKey{}).(*ProxyAuth)
+	return auth
+}
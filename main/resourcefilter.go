@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// ResourceFilter decides which network requests a scrape is allowed to make.
+// BlockedTypes holds the CDP resource types to abort outright (e.g. "Image",
+// "Stylesheet", "Font", "Media"). BlockedPatterns is a list of substrings
+// matched against the request URL, used to cut off third-party trackers and
+// analytics beacons regardless of resource type. AllowedPatterns takes
+// priority over both and is useful for whitelisting a specific host that
+// would otherwise be caught by a blocked type (e.g. a CDN serving the
+// product image we actually want).
+type ResourceFilter struct {
+	BlockedTypes    []string
+	BlockedPatterns []string
+	AllowedPatterns []string
+}
+
+// defaultResourceFilter blocks the resource types that slow scrapes down the
+// most without affecting the scripts that render stock/price information.
+func defaultResourceFilter() ResourceFilter {
+	return ResourceFilter{
+		BlockedTypes: []string{"Image", "Stylesheet", "Font", "Media"},
+		BlockedPatterns: []string{
+			"google-analytics.com",
+			"googletagmanager.com",
+			"doubleclick.net",
+			"facebook.net",
+			"hotjar.com",
+			"segment.io",
+		},
+	}
+}
+
+// Allows reports whether the request described by url/resourceType should be
+// allowed through.
+func (f ResourceFilter) Allows(url, resourceType string) bool {
+	for _, pattern := range f.AllowedPatterns {
+		if strings.Contains(url, pattern) {
+			return true
+		}
+	}
+
+	for _, blockedType := range f.BlockedTypes {
+		if strings.EqualFold(blockedType, resourceType) {
+			return false
+		}
+	}
+
+	for _, pattern := range f.BlockedPatterns {
+		if strings.Contains(url, pattern) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// enableResourceFiltering turns on the CDP fetch domain for ctx and wires up
+// a RequestPaused handler that continues or fails requests according to
+// filter. It replaces the old blink-settings=scriptEnabled=false approach,
+// which blocked whole resource classes indiscriminately and broke JS-heavy
+// pages that need scripts to render stock/price.
+//
+// If ctx carries a ProxyAuth (see withProxyAuth), this also answers the
+// proxy's CDP auth challenge so navigation doesn't stall behind a 407.
+func enableResourceFiltering(ctx context.Context, filter ResourceFilter) error {
+	auth := proxyAuthFromContext(ctx)
+
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *fetch.EventRequestPaused:
+			go handleRequestPaused(ctx, ev, filter)
+		case *fetch.EventAuthRequired:
+			go handleAuthRequired(ctx, ev, auth)
+		}
+	})
+
+	enableParams := fetch.Enable().WithPatterns([]*fetch.RequestPattern{
+		{URLPattern: "*", RequestStage: fetch.RequestStageRequest},
+	})
+	if auth != nil {
+		enableParams = enableParams.WithHandleAuthRequests(true)
+	}
+
+	return chromedp.Run(ctx, enableParams)
+}
+
+func handleRequestPaused(ctx context.Context, ev *fetch.EventRequestPaused, filter ResourceFilter) {
+	c := chromedp.FromContext(ctx)
+	execCtx := cdp.WithExecutor(ctx, c.Target)
+
+	if filter.Allows(ev.Request.URL, string(ev.ResourceType)) {
+		if err := fetch.ContinueRequest(ev.RequestID).Do(execCtx); err != nil {
+			log.Printf("fetch.ContinueRequest failed for %s: %v", ev.Request.URL, err)
+		}
+		return
+	}
+
+	if err := fetch.FailRequest(ev.RequestID, network.ErrorReasonBlockedByClient).Do(execCtx); err != nil {
+		log.Printf("fetch.FailRequest failed for %s: %v", ev.Request.URL, err)
+	}
+}
+
+func handleAuthRequired(ctx context.Context, ev *fetch.EventAuthRequired, auth *ProxyAuth) {
+	c := chromedp.FromContext(ctx)
+	execCtx := cdp.WithExecutor(ctx, c.Target)
+
+	response := &fetch.AuthChallengeResponse{Response: fetch.AuthChallengeResponseResponseDefault}
+	if auth != nil && ev.AuthChallenge.Source == fetch.AuthChallengeSourceProxy {
+		response = &fetch.AuthChallengeResponse{
+			Response: fetch.AuthChallengeResponseResponseProvideCredentials,
+			Username: auth.Username,
+			Password: auth.Password,
+		}
+	}
+
+	if err := fetch.ContinueWithAuth(ev.RequestID, response).Do(execCtx); err != nil {
+		log.Printf("fetch.ContinueWithAuth failed: %v", err)
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/storage"
+	"github.com/chromedp/chromedp"
+)
+
+// Result is the outcome of scraping a single URL through a ScrapePool.
+// Challenge is set when Err is non-nil and the page looked like an anti-bot
+// interstitial rather than an ordinary timeout.
+type Result struct {
+	Product   Product
+	Challenge ChallengeType
+	Err       error
+}
+
+type job struct {
+	url      string
+	resultCh chan Result
+}
+
+// ScrapePool runs scrapes across a fixed number of worker goroutines, each
+// driving its own long-lived browser process so a worker's tabs amortize the
+// multi-second cost of launching Chrome over many scrapes instead of paying
+// it per URL. When proxyPool is configured each worker's browser is bound to
+// a distinct proxy (chromedp can only set --proxy-server per process, not
+// per tab), rotated on repeated failure.
+type ScrapePool struct {
+	concurrency int
+	maxTabUses  int
+	proxyPool   *ProxyPool
+
+	jobs     chan job
+	done     chan struct{}
+	stopping chan struct{}
+	stopOnce sync.Once
+
+	registryMu sync.RWMutex
+	registry   *ScraperRegistry
+}
+
+// errPoolShuttingDown is returned by Submit for any job that arrives after
+// Shutdown has been called.
+var errPoolShuttingDown = fmt.Errorf("scrape pool is shutting down")
+
+// NewScrapePool starts concurrency workers, each recycling its tab after
+// maxTabUses scrapes. proxyPool may be nil, in which case workers launch
+// without a proxy.
+func NewScrapePool(concurrency, maxTabUses int, registry *ScraperRegistry, proxyPool *ProxyPool) (*ScrapePool, error) {
+	p := &ScrapePool{
+		concurrency: concurrency,
+		maxTabUses:  maxTabUses,
+		proxyPool:   proxyPool,
+		jobs:        make(chan job),
+		done:        make(chan struct{}),
+		stopping:    make(chan struct{}),
+		registry:    registry,
+	}
+
+	go p.run()
+
+	return p, nil
+}
+
+// SetRegistry swaps the registry used to pick a Scraper for future
+// submissions, letting callers pick up manifest changes between scrape
+// cycles without restarting the pool.
+func (p *ScrapePool) SetRegistry(registry *ScraperRegistry) {
+	p.registryMu.Lock()
+	defer p.registryMu.Unlock()
+	p.registry = registry
+}
+
+func (p *ScrapePool) currentRegistry() *ScraperRegistry {
+	p.registryMu.RLock()
+	defer p.registryMu.RUnlock()
+	return p.registry
+}
+
+func (p *ScrapePool) run() {
+	workerDone := make(chan struct{})
+	for i := 0; i < p.concurrency; i++ {
+		go func() {
+			p.worker()
+			workerDone <- struct{}{}
+		}()
+	}
+	for i := 0; i < p.concurrency; i++ {
+		<-workerDone
+	}
+	close(p.done)
+}
+
+// workerBrowser bundles a worker's current browser process with the proxy
+// it was launched with (if any), so the worker can report success/failure
+// back to the proxy pool and relaunch with a fresh proxy when needed.
+type workerBrowser struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	proxy    Proxy
+	hasProxy bool
+}
+
+func (p *ScrapePool) launchBrowser() (workerBrowser, error) {
+	opts := append(chromedp.DefaultExecAllocatorOptions[:], commonChromeFlags(true)...)
+
+	var proxy Proxy
+	hasProxy := false
+	if p.proxyPool != nil {
+		if acquired, ok := p.proxyPool.Acquire(); ok {
+			proxy = acquired
+			hasProxy = true
+			opts = append(opts, chromedp.ProxyServer(proxy.Address))
+		} else {
+			log.Printf("No healthy proxies available, launching without one")
+		}
+	}
+
+	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), opts...)
+	browserCtx, browserCancel := chromedp.NewContext(allocCtx)
+
+	if err := chromedp.Run(browserCtx); err != nil {
+		browserCancel()
+		allocCancel()
+		return workerBrowser{}, fmt.Errorf("launching browser: %w", err)
+	}
+
+	return workerBrowser{
+		ctx: browserCtx,
+		cancel: func() {
+			browserCancel()
+			allocCancel()
+		},
+		proxy:    proxy,
+		hasProxy: hasProxy,
+	}, nil
+}
+
+func commonChromeFlags(headless bool) []chromedp.ExecAllocatorOption {
+	opts := []chromedp.ExecAllocatorOption{
+		chromedp.DisableGPU,
+		chromedp.NoDefaultBrowserCheck,
+		chromedp.NoFirstRun,
+		chromedp.Flag("disable-blink-features", "AutomationControlled"),
+		chromedp.NoSandbox,
+		chromedp.Flag("ignore-certificate-errors", "true"),
+		chromedp.Flag("disable-http2", "true"), // Experimental flag – may or may not help.
+		chromedp.Flag("disable-extensions", "true"),
+	}
+	if headless {
+		opts = append(opts, chromedp.Headless)
+	}
+	return opts
+}
+
+// attemptHeadfulRetry is the last-resort fallback for a URL that failed
+// behind a hard anti-bot block: some challenges (PerimeterX, DataDome) key
+// off headless-mode signals that a real, headful Chrome doesn't trip.
+func attemptHeadfulRetry(url string, registry *ScraperRegistry) (Product, error) {
+	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), commonChromeFlags(false)...)
+	defer allocCancel()
+
+	browserCtx, browserCancel := chromedp.NewContext(allocCtx)
+	defer browserCancel()
+
+	ctx, timeoutCancel := context.WithTimeout(browserCtx, 45*time.Second)
+	defer timeoutCancel()
+
+	return registry.For(url).Extract(ctx, url)
+}
+
+func (p *ScrapePool) worker() {
+	browser, err := p.launchBrowser()
+	if err != nil {
+		log.Printf("Worker failed to launch browser: %v", err)
+		return
+	}
+	// browser is reassigned on proxy rotation below, so the deferred cleanup
+	// must read it through a closure rather than capture today's cancel func.
+	defer func() { browser.cancel() }()
+
+	var tabCtx context.Context
+	var tabCancel context.CancelFunc
+	uses := 0
+
+	closeTab := func() {
+		if tabCancel != nil {
+			tabCancel()
+			tabCtx, tabCancel = nil, nil
+		}
+	}
+	defer closeTab()
+
+	for {
+		var j job
+		select {
+		case j = <-p.jobs:
+		case <-p.stopping:
+			return
+		}
+
+		if tabCtx == nil || uses >= p.maxTabUses {
+			closeTab()
+			tabCtx, tabCancel = chromedp.NewContext(browser.ctx)
+			uses = 0
+		}
+
+		var auth *ProxyAuth
+		if browser.hasProxy && browser.proxy.Username != "" {
+			auth = &ProxyAuth{Username: browser.proxy.Username, Password: browser.proxy.Password}
+		}
+
+		product, challenge, err := scrapeURL(withProxyAuth(tabCtx, auth), j.url, p.currentRegistry())
+		uses++
+
+		if clearErr := clearTabStorage(tabCtx, j.url); clearErr != nil {
+			log.Printf("Failed to clear tab storage after %s: %v", j.url, clearErr)
+		}
+
+		// A hard anti-bot block is a last-resort fallback: try once more
+		// from a fresh, headful browser before giving up on this URL.
+		if err != nil && challenge != ChallengeNone && !challenge.IsTransient() {
+			log.Printf("Hard block (%s) detected for %s, retrying headful", challenge, j.url)
+			if headfulProduct, headfulErr := attemptHeadfulRetry(j.url, p.currentRegistry()); headfulErr == nil {
+				product, err, challenge = headfulProduct, nil, ChallengeNone
+			}
+		}
+
+		if p.proxyPool != nil && browser.hasProxy {
+			if err != nil {
+				p.proxyPool.ReportFailure(browser.proxy)
+			} else {
+				p.proxyPool.ReportSuccess(browser.proxy)
+			}
+		}
+
+		j.resultCh <- Result{Product: product, Challenge: challenge, Err: err}
+		close(j.resultCh)
+
+		// On failure, rotate to a fresh proxy+browser for the next job
+		// rather than keep hammering a target through one that's blocked.
+		if err != nil && p.proxyPool != nil {
+			closeTab()
+			browser.cancel()
+			newBrowser, launchErr := p.launchBrowser()
+			if launchErr != nil {
+				log.Printf("Worker failed to relaunch browser after failure: %v", launchErr)
+				return
+			}
+			browser = newBrowser
+		}
+	}
+}
+
+// Submit enqueues url for scraping and returns a channel that receives
+// exactly one Result once a worker has processed it. If Shutdown has already
+// been called (e.g. a SIGTERM arrived mid-cycle), it returns immediately with
+// errPoolShuttingDown instead of blocking on a pool that will never pick the
+// job up. p.jobs itself is never closed, so there's no "send on closed
+// channel" race between this goroutine and the one calling Shutdown.
+func (p *ScrapePool) Submit(url string) <-chan Result {
+	resultCh := make(chan Result, 1)
+	select {
+	case p.jobs <- job{url: url, resultCh: resultCh}:
+	case <-p.stopping:
+		resultCh <- Result{Err: errPoolShuttingDown}
+	}
+	return resultCh
+}
+
+// Shutdown stops accepting new work and waits for in-flight scrapes to
+// finish; each worker closes its own browser as it exits. It is safe to
+// call from a SIGTERM handler to make sure no headless Chrome process is
+// left running after the process exits.
+func (p *ScrapePool) Shutdown() {
+	p.stopOnce.Do(func() { close(p.stopping) })
+	<-p.done
+}
+
+// clearTabStorage wipes cookies and storage for url's origin so the next
+// scrape to reuse this tab doesn't inherit session state from the last one.
+func clearTabStorage(ctx context.Context, rawURL string) error {
+	origin, err := originOf(rawURL)
+	if err != nil {
+		return err
+	}
+	return storage.ClearDataForOrigin(origin, "all").Do(ctx)
+}
+
+// scrapeURL drives a single tab through navigation and extraction via the
+// Scraper registered for url's host, returning the scraped Product. On
+// failure it inspects the page for known anti-bot signals: a transient
+// challenge (e.g. Cloudflare's JS interstitial) gets one retry after a short
+// wait, since it often passes on its own. Either way, it takes a best-effort
+// screenshot of whatever the tab was showing, to aid debugging.
+func scrapeURL(ctx context.Context, url string, registry *ScraperRegistry) (Product, ChallengeType, error) {
+	ctx, timeoutCancel := context.WithTimeout(ctx, 60*time.Second)
+	defer timeoutCancel()
+
+	log.Printf("Navigating to: %s", url)
+
+	scraper := registry.For(url)
+	product, err := scraper.Extract(ctx, url)
+
+	challenge := ChallengeNone
+	if err != nil {
+		challenge = detectChallenge(ctx)
+		if challenge.IsTransient() {
+			log.Printf("Transient challenge (%s) for %s, waiting %s before retry", challenge, url, challengeWaitDuration)
+			time.Sleep(challengeWaitDuration)
+			if retryProduct, retryErr := scraper.Extract(ctx, url); retryErr == nil {
+				product, err, challenge = retryProduct, nil, ChallengeNone
+			} else {
+				challenge = detectChallenge(ctx)
+			}
+		}
+	}
+
+	if err != nil {
+		if buf, screenshotErr := screenshotTab(ctx); screenshotErr == nil {
+			filename := fmt.Sprintf("screenshots/screenshot_%d.png", time.Now().UnixNano())
+			if writeErr := writeScreenshot(filename, buf); writeErr == nil {
+				log.Printf("Saved screenshot for %s as %s", url, filename)
+			} else {
+				log.Printf("Failed to write screenshot: %v", writeErr)
+			}
+		}
+		return Product{}, challenge, err
+	}
+
+	log.Printf("Product: %s", product.Name)
+	log.Printf("In Stock: %v", product.InStock)
+
+	return product, ChallengeNone, nil
+}
+
+func screenshotTab(ctx context.Context) ([]byte, error) {
+	var buf []byte
+	err := chromedp.Run(ctx, chromedp.FullScreenshot(&buf, 90))
+	return buf, err
+}
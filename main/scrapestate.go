@@ -0,0 +1,82 @@
+package main
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ScrapeState is the backoff bookkeeping for a single URL, persisted so a
+// process restart doesn't forget a site is currently blocking us and start
+// hammering it again every loop iteration.
+type ScrapeState struct {
+	URL                 string
+	ConsecutiveFailures int
+	LastChallenge       string
+	BlockedUntil        time.Time
+	UpdatedAt           time.Time
+}
+
+const (
+	scrapeStateBaseBackoff = time.Minute
+	scrapeStateMaxBackoff  = 24 * time.Hour
+)
+
+// nextBackoff returns how long to back off given consecutiveFailures,
+// doubling from scrapeStateBaseBackoff and capping at scrapeStateMaxBackoff.
+func nextBackoff(consecutiveFailures int) time.Duration {
+	if consecutiveFailures <= 0 {
+		return 0
+	}
+	if consecutiveFailures > 20 { // guard against overflow in the shift below
+		return scrapeStateMaxBackoff
+	}
+	backoff := scrapeStateBaseBackoff * time.Duration(uint64(1)<<uint(consecutiveFailures-1))
+	if backoff > scrapeStateMaxBackoff {
+		return scrapeStateMaxBackoff
+	}
+	return backoff
+}
+
+// fetchScrapeState returns url's current backoff state, or sql.ErrNoRows if
+// it has never failed.
+func fetchScrapeState(db *sql.DB, url string) (ScrapeState, error) {
+	var s ScrapeState
+	row := db.QueryRow(`
+        SELECT url, consecutive_failures, last_challenge, blocked_until, updated_at
+        FROM scrape_state WHERE url = $1
+    `, url)
+	err := row.Scan(&s.URL, &s.ConsecutiveFailures, &s.LastChallenge, &s.BlockedUntil, &s.UpdatedAt)
+	return s, err
+}
+
+func upsertScrapeState(db *sql.DB, s ScrapeState) error {
+	_, err := db.Exec(`
+        INSERT INTO scrape_state (url, consecutive_failures, last_challenge, blocked_until, updated_at)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (url) DO UPDATE
+        SET consecutive_failures = $2, last_challenge = $3, blocked_until = $4, updated_at = $5
+    `, s.URL, s.ConsecutiveFailures, s.LastChallenge, s.BlockedUntil, s.UpdatedAt)
+	return err
+}
+
+// recordScrapeFailure bumps url's consecutive-failure count and persists the
+// resulting backoff window.
+func recordScrapeFailure(db *sql.DB, url string, previous ScrapeState, hadPrevious bool, challenge ChallengeType) error {
+	failures := 1
+	if hadPrevious {
+		failures = previous.ConsecutiveFailures + 1
+	}
+	now := time.Now()
+	return upsertScrapeState(db, ScrapeState{
+		URL:                 url,
+		ConsecutiveFailures: failures,
+		LastChallenge:       string(challenge),
+		BlockedUntil:        now.Add(nextBackoff(failures)),
+		UpdatedAt:           now,
+	})
+}
+
+// clearScrapeFailure resets url's backoff state after a successful scrape.
+func clearScrapeFailure(db *sql.DB, url string) error {
+	return upsertScrapeState(db, ScrapeState{URL: url, UpdatedAt: time.Now()})
+}
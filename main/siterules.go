@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// SiteRule describes how to extract a Product from a page, keyed by host in
+// the urls.json manifest. A zero-value field falls back to the selectors in
+// defaultSiteRule, so a manifest entry only needs to override what's
+// different about that retailer's theme. Selectors are plain CSS queried via
+// document.querySelector; there's no XPath or per-site custom JS support,
+// since every retailer we scrape so far has a themed-but-CSS-reachable DOM.
+type SiteRule struct {
+	NameSelector       string `json:"name_selector,omitempty"`
+	OutOfStockSelector string `json:"out_of_stock_selector,omitempty"`
+	WaitFor            string `json:"wait_for,omitempty"`
+	// UseJSONLD is a *bool, not a bool, so an entry that omits it is
+	// distinguishable from one that explicitly sets it to false: merged
+	// falls back to base only in the former case.
+	UseJSONLD *bool `json:"use_json_ld,omitempty"`
+	// ResourceFilter lets a manifest entry whitelist/blacklist URL patterns
+	// and resource types for this host specifically. Unlike the other
+	// fields it isn't merged field-by-field onto the default filter: if
+	// present it replaces the default outright, since there's no sensible
+	// per-pattern "zero value means inherit" for a filter's slices.
+	ResourceFilter *ResourceFilter `json:"resource_filter,omitempty"`
+}
+
+// useJSONLD reports whether r should run the JSON-LD extraction pass.
+func (r SiteRule) useJSONLD() bool {
+	return r.UseJSONLD != nil && *r.UseJSONLD
+}
+
+// defaultSiteRule mirrors the selectors that used to be hard-coded in
+// scrapeProducts. It's used for any host with no matching entry in
+// site_rules, and as the base that manifest overrides are merged onto.
+func defaultSiteRule() SiteRule {
+	useJSONLD := true
+	return SiteRule{
+		NameSelector:       `h1[automation-id="productName"], .product-title, h1`,
+		OutOfStockSelector: `[automation-id="outOfStockMessage"], .out-of-stock-msg, .oos-overlay`,
+		WaitFor:            `h1[automation-id="productName"], .product-title, h1`,
+		UseJSONLD:          &useJSONLD,
+	}
+}
+
+// merged fills any zero-value fields of r with the corresponding field from
+// base, so a manifest entry can override just the selectors it cares about.
+func (r SiteRule) merged(base SiteRule) SiteRule {
+	if r.NameSelector == "" {
+		r.NameSelector = base.NameSelector
+	}
+	if r.OutOfStockSelector == "" {
+		r.OutOfStockSelector = base.OutOfStockSelector
+	}
+	if r.WaitFor == "" {
+		r.WaitFor = base.WaitFor
+	}
+	if r.UseJSONLD == nil {
+		r.UseJSONLD = base.UseJSONLD
+	}
+	return r
+}
+
+// Manifest is the shape of urls.json: the list of product URLs to scrape,
+// plus per-host SiteRules for themes that don't match the default selectors
+// and per-URL price-drop thresholds for notifications.
+type Manifest struct {
+	URLs            []string            `json:"urls"`
+	SiteRules       map[string]SiteRule `json:"site_rules"`
+	PriceThresholds map[string]float64  `json:"price_thresholds"`
+}
+
+func loadManifest(path string) (Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	return manifest, nil
+}
+
+// Scraper extracts a Product from a single URL.
+type Scraper interface {
+	Extract(ctx context.Context, url string) (Product, error)
+}
+
+// ScraperRegistry resolves the Scraper to use for a given URL based on its
+// host, falling back to a default scraper for hosts with no SiteRule.
+type ScraperRegistry struct {
+	byHost   map[string]Scraper
+	fallback Scraper
+}
+
+// NewScraperRegistry builds a registry from a manifest's site_rules.
+// defaultFilter is used for any host whose SiteRule doesn't set its own
+// ResourceFilter, so most manifest entries don't need to think about
+// filtering at all.
+func NewScraperRegistry(manifest Manifest, defaultFilter ResourceFilter) *ScraperRegistry {
+	base := defaultSiteRule()
+
+	reg := &ScraperRegistry{
+		byHost:   make(map[string]Scraper, len(manifest.SiteRules)),
+		fallback: &RuleScraper{rule: base, filter: defaultFilter},
+	}
+
+	for host, rule := range manifest.SiteRules {
+		filter := defaultFilter
+		if rule.ResourceFilter != nil {
+			filter = *rule.ResourceFilter
+		}
+		reg.byHost[host] = &RuleScraper{rule: rule.merged(base), filter: filter}
+	}
+
+	return reg
+}
+
+// For returns the Scraper registered for rawURL's host, or the default
+// scraper if no SiteRule matches.
+func (r *ScraperRegistry) For(rawURL string) Scraper {
+	if host, err := hostOf(rawURL); err == nil {
+		if s, ok := r.byHost[host]; ok {
+			return s
+		}
+	}
+	return r.fallback
+}
+
+// RuleScraper implements Scraper using a SiteRule's CSS selectors, with an
+// optional JSON-LD pass to fill in fields (price, image) that plain selector
+// scraping leaves empty. Microdata (itemprop attributes) is not read.
+type RuleScraper struct {
+	rule   SiteRule
+	filter ResourceFilter
+}
+
+// productLD is the subset of schema.org Product/Offer fields we care about,
+// as extracted from a page's <script type="application/ld+json"> tags.
+type productLD struct {
+	Name         string `json:"name"`
+	Price        string `json:"price"`
+	Availability string `json:"availability"`
+	Image        string `json:"image"`
+}
+
+const jsonLDExtractScript = `
+(() => {
+	const scripts = document.querySelectorAll('script[type="application/ld+json"]');
+	for (const script of scripts) {
+		let data;
+		try {
+			data = JSON.parse(script.textContent);
+		} catch (e) {
+			continue;
+		}
+		const items = Array.isArray(data) ? data : (data['@graph'] || [data]);
+		for (const item of items) {
+			const types = Array.isArray(item['@type']) ? item['@type'] : [item['@type']];
+			if (!types.includes('Product')) continue;
+			const offers = Array.isArray(item.offers) ? item.offers[0] : item.offers;
+			const image = Array.isArray(item.image) ? item.image[0] : item.image;
+			return JSON.stringify({
+				name: item.name || '',
+				price: offers && offers.price != null ? String(offers.price) : '',
+				availability: offers && offers.availability ? offers.availability : '',
+				image: image || '',
+			});
+		}
+	}
+	return '';
+})()
+`
+
+func (s *RuleScraper) Extract(ctx context.Context, url string) (Product, error) {
+	if err := enableResourceFiltering(ctx, s.filter); err != nil {
+		return Product{}, fmt.Errorf("enabling resource filtering: %w", err)
+	}
+
+	var name string
+	var outOfStock bool
+	var ldJSON string
+
+	fp := randomFingerprint()
+
+	actions := []chromedp.Action{
+		network.Enable(),
+		emulation.SetUserAgentOverride(fp.UserAgent).WithAcceptLanguage(fp.AcceptLanguage),
+		emulation.SetTimezoneOverride(fp.Timezone),
+		chromedp.EmulateViewport(fp.ViewportWidth, fp.ViewportHeight),
+		chromedp.Navigate(url),
+		chromedp.WaitReady("body"),
+		chromedp.WaitVisible(s.rule.WaitFor, chromedp.ByQuery),
+		chromedp.EvaluateAsDevTools(fmt.Sprintf(`
+			(() => {
+				const el = document.querySelector(%[1]q);
+				return el ? el.textContent.trim() : '';
+			})()
+		`, s.rule.NameSelector), &name),
+		chromedp.EvaluateAsDevTools(fmt.Sprintf(`!!document.querySelector(%[1]q)`, s.rule.OutOfStockSelector), &outOfStock),
+	}
+	if s.rule.useJSONLD() {
+		actions = append(actions, chromedp.EvaluateAsDevTools(jsonLDExtractScript, &ldJSON))
+	}
+
+	if err := chromedp.Run(ctx, actions...); err != nil {
+		return Product{}, fmt.Errorf("scraping %s: %w", url, err)
+	}
+
+	product := Product{
+		URL:       url,
+		Name:      name,
+		InStock:   !outOfStock,
+		UpdatedAt: time.Now(),
+	}
+
+	if ld, err := parseProductLD(ldJSON); err == nil {
+		if product.Name == "" {
+			product.Name = ld.Name
+		}
+		product.ImageURL = ld.Image
+		if price, err := parsePrice(ld.Price); err == nil {
+			product.Price = price
+		}
+		if ld.Availability != "" {
+			product.InStock = strings.Contains(strings.ToLower(ld.Availability), "instock")
+		}
+	}
+
+	return product, nil
+}
+
+func parseProductLD(raw string) (productLD, error) {
+	if raw == "" {
+		return productLD{}, fmt.Errorf("no JSON-LD Product found")
+	}
+	var ld productLD
+	if err := json.Unmarshal([]byte(raw), &ld); err != nil {
+		return productLD{}, fmt.Errorf("parsing JSON-LD: %w", err)
+	}
+	return ld, nil
+}
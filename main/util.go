@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// originOf returns the scheme://host[:port] origin of rawURL, as expected by
+// CDP's storage.ClearDataForOrigin.
+func originOf(rawURL string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing URL %q: %w", rawURL, err)
+	}
+	if parsed.Scheme == "" || parsed.Host == "" {
+		return "", fmt.Errorf("URL %q has no scheme/host", rawURL)
+	}
+	return parsed.Scheme + "://" + parsed.Host, nil
+}
+
+// hostOf returns the hostname (no port) of rawURL, used to key the
+// per-site extractor registry.
+func hostOf(rawURL string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing URL %q: %w", rawURL, err)
+	}
+	if parsed.Hostname() == "" {
+		return "", fmt.Errorf("URL %q has no host", rawURL)
+	}
+	return parsed.Hostname(), nil
+}
+
+// parsePrice parses a schema.org price string (e.g. "19.99") into a float.
+func parsePrice(raw string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSpace(raw), 64)
+}
+
+// parseHistoryPath extracts the product ID from a "/api/products/{id}/history"
+// request path.
+func parseHistoryPath(path string) (int, bool) {
+	parts := strings.Split(strings.TrimPrefix(path, "/api/products/"), "/")
+	if len(parts) != 2 || parts[1] != "history" {
+		return 0, false
+	}
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+func writeScreenshot(filename string, buf []byte) error {
+	return os.WriteFile(filename, buf, 0644)
+}